@@ -0,0 +1,150 @@
+// Package auth gates write/read access to a referer's counters behind a
+// JWT bearer token carrying a scopes claim.
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/metadata"
+)
+
+const scopesKey = "auth.scopes"
+
+// Claims is the JWT payload statscollector expects: the standard
+// registered claims plus a scopes list of referers the bearer may act on.
+type Claims struct {
+	Scopes []string `json:"scopes"`
+	jwt.RegisteredClaims
+}
+
+// Middleware validates the Authorization bearer token against secret and
+// stores its scopes in the gin context for RequireRefererScope to check.
+func Middleware(secret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, err := parseBearer(c.GetHeader("Authorization"), secret)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(scopesKey, scopes)
+		c.Next()
+	}
+}
+
+// RequireRefererScope rejects the request unless the token scopes set by
+// Middleware include every referer being acted on. Referers are read from
+// the URL param named "referer" when present, otherwise peeked from the
+// JSON request body (a single hit object or a /api/batch array of them)
+// without consuming it for the handler.
+func RequireRefererScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		referers := referersForRequest(c)
+		if len(referers) == 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "no referer found in request"})
+			return
+		}
+
+		v, _ := c.Get(scopesKey)
+		scopes, _ := v.([]string)
+		for _, referer := range referers {
+			if !ScopesContain(scopes, referer) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "referer not in token scopes: " + referer})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// ScopesContain reports whether scopes authorizes referer.
+func ScopesContain(scopes []string, referer string) bool {
+	for _, s := range scopes {
+		if s == referer {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopesFromIncomingContext validates the bearer token carried in the
+// gRPC "authorization" metadata against secret and returns its scopes,
+// for services (like grpcserver.Server) that have no gin.Context.
+func ScopesFromIncomingContext(ctx context.Context, secret []byte) ([]string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, errors.New("missing metadata")
+	}
+	vals := md.Get("authorization")
+	if len(vals) == 0 {
+		return nil, errors.New("missing bearer token")
+	}
+	return parseBearer(vals[0], secret)
+}
+
+func parseBearer(header string, secret []byte) ([]string, error) {
+	tokenStr := strings.TrimPrefix(header, "Bearer ")
+	if tokenStr == "" || tokenStr == header {
+		return nil, errors.New("missing bearer token")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(*jwt.Token) (interface{}, error) {
+		return secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims.Scopes, nil
+}
+
+// referersForRequest collects every referer the request would act on: the
+// URL param if the route has one, otherwise every distinct referer found
+// in the JSON body (a single hit object or a /api/batch array).
+func referersForRequest(c *gin.Context) []string {
+	if referer := c.Param("referer"); referer != "" {
+		return []string{referer}
+	}
+	return referersFromBody(c)
+}
+
+func referersFromBody(c *gin.Context) []string {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var single struct {
+		Referer string `json:"referer"`
+	}
+	if err := json.Unmarshal(body, &single); err == nil && single.Referer != "" {
+		return []string{single.Referer}
+	}
+
+	var batch []struct {
+		Referer string `json:"referer"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(batch))
+	var referers []string
+	for _, hit := range batch {
+		if hit.Referer == "" {
+			continue
+		}
+		if _, ok := seen[hit.Referer]; !ok {
+			seen[hit.Referer] = struct{}{}
+			referers = append(referers, hit.Referer)
+		}
+	}
+	return referers
+}
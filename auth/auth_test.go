@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestContext(method, body string, param string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/", bytes.NewBufferString(body))
+	if param != "" {
+		c.Params = gin.Params{{Key: "referer", Value: param}}
+	}
+	return c, w
+}
+
+func TestRequireRefererScope(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       string
+		param      string
+		scopes     []string
+		wantStatus int
+	}{
+		{"url param in scope", "", "hotpop", []string{"hotpop"}, http.StatusOK},
+		{"url param out of scope", "", "hotpop", []string{"other"}, http.StatusForbidden},
+		{"single hit body in scope", `{"referer":"hotpop","urls":["a"]}`, "", []string{"hotpop"}, http.StatusOK},
+		{"single hit body out of scope", `{"referer":"hotpop","urls":["a"]}`, "", []string{"other"}, http.StatusForbidden},
+		{"batch body all in scope", `[{"referer":"a","url":"u1"},{"referer":"b","url":"u2"}]`, "", []string{"a", "b"}, http.StatusOK},
+		{"batch body partially out of scope", `[{"referer":"a","url":"u1"},{"referer":"b","url":"u2"}]`, "", []string{"a"}, http.StatusForbidden},
+		{"no referer found", `{}`, "", []string{"hotpop"}, http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodPost, tc.body, tc.param)
+			c.Set(scopesKey, tc.scopes)
+
+			RequireRefererScope()(c)
+
+			if tc.wantStatus == http.StatusOK {
+				if w.Code != 0 && w.Code != http.StatusOK {
+					t.Fatalf("expected request to pass through, got status %d", w.Code)
+				}
+				return
+			}
+			if w.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestScopesContain(t *testing.T) {
+	scopes := []string{"hotpop", "coldpop"}
+	if !ScopesContain(scopes, "hotpop") {
+		t.Fatal("expected hotpop to be in scope")
+	}
+	if ScopesContain(scopes, "nope") {
+		t.Fatal("expected nope to not be in scope")
+	}
+}
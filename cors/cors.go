@@ -0,0 +1,149 @@
+// Package cors implements a configurable CORS allow-list, replacing a
+// hardcoded Access-Control-Allow-Origin: * that, combined with
+// Allow-Credentials: true, browsers ignore anyway.
+package cors
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Env vars read by LoadConfig. CORS_ALLOWED_ORIGINS and
+// CORS_MAX_AGE_SECONDS are read only when CORS_CONFIG_FILE is not set.
+const (
+	OriginsEnv    = "CORS_ALLOWED_ORIGINS"
+	MaxAgeEnv     = "CORS_MAX_AGE_SECONDS"
+	ConfigFileEnv = "CORS_CONFIG_FILE"
+)
+
+// DefaultMaxAge is the preflight cache TTL, in seconds, used when none is
+// configured.
+const DefaultMaxAge = 86400
+
+// Config is the CORS allow-list. Origins may be exact origins
+// (https://example.com) or a wildcard subdomain pattern
+// (https://*.example.com); matching is case-insensitive.
+type Config struct {
+	Origins []string `yaml:"origins"`
+	MaxAge  int      `yaml:"max_age_seconds"`
+}
+
+// LoadConfig builds a Config from CORS_CONFIG_FILE if set, otherwise from
+// CORS_ALLOWED_ORIGINS (a comma-separated list) and CORS_MAX_AGE_SECONDS.
+func LoadConfig() (Config, error) {
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		return loadConfigFile(path)
+	}
+
+	cfg := Config{MaxAge: DefaultMaxAge}
+	if raw := os.Getenv(OriginsEnv); raw != "" {
+		for _, origin := range strings.Split(raw, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.Origins = append(cfg.Origins, origin)
+			}
+		}
+	}
+	if raw := os.Getenv(MaxAgeEnv); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil {
+			cfg.MaxAge = n
+		}
+	}
+	return cfg, nil
+}
+
+func loadConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := Config{MaxAge: DefaultMaxAge}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Middleware enforces cfg's origin allow-list. When the request Origin
+// matches, it is echoed back verbatim (never "*") so that
+// Allow-Credentials: true is actually honored by the browser.
+func Middleware(cfg Config) gin.HandlerFunc {
+	allowed := newMatcher(cfg.Origins)
+	maxAge := cfg.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	maxAgeStr := strconv.Itoa(maxAge)
+
+	return func(c *gin.Context) {
+		if origin := c.GetHeader("Origin"); origin != "" && allowed.matches(origin) {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Set("Vary", "Origin")
+		}
+		c.Writer.Header().Set("Access-Control-Max-Age", maxAgeStr)
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(200)
+			return
+		}
+		c.Next()
+	}
+}
+
+// matcher checks an Origin header against exact and wildcard-subdomain
+// allow-list entries, case-insensitively.
+type matcher struct {
+	exact    map[string]struct{}
+	wildcard []*regexp.Regexp
+}
+
+func newMatcher(origins []string) *matcher {
+	m := &matcher{exact: make(map[string]struct{})}
+	for _, origin := range origins {
+		origin = strings.ToLower(origin)
+		if re, ok := wildcardPattern(origin); ok {
+			m.wildcard = append(m.wildcard, re)
+			continue
+		}
+		m.exact[origin] = struct{}{}
+	}
+	return m
+}
+
+// wildcardPattern turns a pattern like "https://*.example.com" into a
+// regexp matching any single subdomain under example.com.
+func wildcardPattern(origin string) (*regexp.Regexp, bool) {
+	const marker = "*."
+	i := strings.Index(origin, marker)
+	if i < 0 {
+		return nil, false
+	}
+	scheme, suffix := origin[:i], origin[i+len(marker):]
+	pattern := "^" + regexp.QuoteMeta(scheme) + `[a-z0-9-]+\.` + regexp.QuoteMeta(suffix) + "$"
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, false
+	}
+	return re, true
+}
+
+func (m *matcher) matches(origin string) bool {
+	origin = strings.ToLower(origin)
+	if _, ok := m.exact[origin]; ok {
+		return true
+	}
+	for _, re := range m.wildcard {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package cors
+
+import "testing"
+
+func TestMatcherMatches(t *testing.T) {
+	m := newMatcher([]string{"https://example.com", "https://*.example.com"})
+
+	cases := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"exact match", "https://example.com", true},
+		{"exact match case-insensitive", "HTTPS://EXAMPLE.COM", true},
+		{"wildcard subdomain", "https://api.example.com", true},
+		{"wildcard subdomain case-insensitive", "https://API.Example.Com", true},
+		{"wildcard does not match lookalike suffix domain", "https://example.com.evil.com", false},
+		{"wildcard does not match nested subdomain", "https://a.b.example.com", false},
+		{"different scheme rejected", "http://example.com", false},
+		{"unrelated origin rejected", "https://other.com", false},
+		{"empty origin rejected", "", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := m.matches(tc.origin); got != tc.want {
+				t.Errorf("matches(%q) = %v, want %v", tc.origin, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatcherEmptyAllowList(t *testing.T) {
+	m := newMatcher(nil)
+	if m.matches("https://example.com") {
+		t.Fatal("expected no origins to match an empty allow-list")
+	}
+}
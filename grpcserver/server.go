@@ -0,0 +1,79 @@
+// Package grpcserver implements the StatsCollector gRPC service, giving
+// backend-to-backend clients a binary ingestion path alongside the REST
+// /api/batch endpoint.
+package grpcserver
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/recoilme/statscollector/auth"
+	"github.com/recoilme/statscollector/proto"
+	"github.com/recoilme/statscollector/store"
+)
+
+// Server implements proto.StatsCollectorServer on top of a store.Store.
+type Server struct {
+	proto.UnimplementedStatsCollectorServer
+
+	st     *store.Store
+	secret []byte
+}
+
+// New returns a Server that applies ingested hits to st. When jwtSecret
+// is non-empty, Ingest requires a bearer token (same scheme as the HTTP
+// JWT auth) whose scopes cover every referer in the batch.
+func New(st *store.Store, jwtSecret string) *Server {
+	s := &Server{st: st}
+	if jwtSecret != "" {
+		s.secret = []byte(jwtSecret)
+	}
+	return s
+}
+
+// Ingest applies every hit that has a non-empty url and a valid referer
+// to the store and reports how many were accepted; other hits are
+// skipped. When auth is enabled, every such hit's referer is checked
+// against the token scopes before any hit is applied.
+func (s *Server) Ingest(ctx context.Context, batch *proto.HitBatch) (*proto.BatchAck, error) {
+	if len(s.secret) > 0 {
+		scopes, err := auth.ScopesFromIncomingContext(ctx, s.secret)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		for _, hit := range batch.GetHits() {
+			if hit.GetUrl() == "" || !store.ValidReferer(hit.GetReferer()) {
+				continue
+			}
+			if !auth.ScopesContain(scopes, hit.GetReferer()) {
+				return nil, status.Error(codes.PermissionDenied, "referer not in token scopes: "+hit.GetReferer())
+			}
+		}
+	}
+
+	var accepted uint64
+	for _, hit := range batch.GetHits() {
+		if hit.GetUrl() == "" || !store.ValidReferer(hit.GetReferer()) {
+			continue
+		}
+
+		path := store.ViewPath(hit.GetReferer())
+		if hit.GetType() == proto.EventType_CLICK {
+			path = store.ClickPath(hit.GetReferer())
+		}
+		count := hit.GetCount()
+		if count == 0 {
+			count = 1
+		}
+		ts := time.Now()
+		if hit.GetTimestamp() != 0 {
+			ts = time.Unix(hit.GetTimestamp(), 0)
+		}
+		s.st.AddBucket(path, []byte(hit.GetUrl()), count, ts)
+		accepted++
+	}
+	return &proto.BatchAck{Accepted: accepted}, nil
+}
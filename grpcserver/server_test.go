@@ -0,0 +1,110 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/recoilme/statscollector/auth"
+	"github.com/recoilme/statscollector/proto"
+	"github.com/recoilme/statscollector/store"
+)
+
+func bearerContext(t *testing.T, secret []byte, scopes []string) context.Context {
+	t.Helper()
+	claims := auth.Claims{Scopes: scopes}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	md := metadata.Pairs("authorization", "Bearer "+token)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestIngestRequiresScope(t *testing.T) {
+	secret := []byte("test-secret")
+	s := New(store.New(time.Hour), string(secret))
+	defer s.st.Close()
+
+	batch := &proto.HitBatch{Hits: []*proto.Hit{{Referer: "hotpop", Url: "u1"}}}
+
+	ctx := bearerContext(t, secret, []string{"other"})
+	if _, err := s.Ingest(ctx, batch); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for out-of-scope referer, got %v", err)
+	}
+}
+
+func TestIngestAcceptsInScope(t *testing.T) {
+	secret := []byte("test-secret")
+	s := New(store.New(time.Hour), string(secret))
+	defer s.st.Close()
+
+	batch := &proto.HitBatch{Hits: []*proto.Hit{{Referer: "hotpop", Url: "u1"}}}
+
+	ctx := bearerContext(t, secret, []string{"hotpop"})
+	ack, err := s.Ingest(ctx, batch)
+	if err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+	if ack.GetAccepted() != 1 {
+		t.Fatalf("Accepted = %d, want 1", ack.GetAccepted())
+	}
+}
+
+func TestIngestRejectsMissingToken(t *testing.T) {
+	secret := []byte("test-secret")
+	s := New(store.New(time.Hour), string(secret))
+	defer s.st.Close()
+
+	batch := &proto.HitBatch{Hits: []*proto.Hit{{Referer: "hotpop", Url: "u1"}}}
+
+	if _, err := s.Ingest(context.Background(), batch); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without a token, got %v", err)
+	}
+}
+
+func TestIngestRejectsInvalidReferer(t *testing.T) {
+	s := New(store.New(time.Hour), "")
+	defer s.st.Close()
+
+	cases := []struct {
+		name    string
+		referer string
+	}{
+		{"path traversal", "../../../etc/cron.d/x"},
+		{"contains slash", "a/b"},
+		{"empty", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			batch := &proto.HitBatch{Hits: []*proto.Hit{{Referer: tc.referer, Url: "u1"}}}
+			ack, err := s.Ingest(context.Background(), batch)
+			if err != nil {
+				t.Fatalf("Ingest() error = %v", err)
+			}
+			if ack.GetAccepted() != 0 {
+				t.Fatalf("Accepted = %d, want 0 for referer %q", ack.GetAccepted(), tc.referer)
+			}
+		})
+	}
+}
+
+func TestIngestAcceptsValidReferer(t *testing.T) {
+	s := New(store.New(time.Hour), "")
+	defer s.st.Close()
+
+	batch := &proto.HitBatch{Hits: []*proto.Hit{{Referer: "hotpop", Url: "u1"}}}
+	ack, err := s.Ingest(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+	if ack.GetAccepted() != 1 {
+		t.Fatalf("Accepted = %d, want 1", ack.GetAccepted())
+	}
+}
@@ -2,21 +2,43 @@ package main
 
 import (
 	"context"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 
-	sp "github.com/recoilme/slowpoke"
+	"github.com/recoilme/statscollector/auth"
+	"github.com/recoilme/statscollector/cors"
+	"github.com/recoilme/statscollector/grpcserver"
+	"github.com/recoilme/statscollector/metrics"
+	"github.com/recoilme/statscollector/proto"
+	"github.com/recoilme/statscollector/store"
 	//"github.com/thinkerou/favicon"
 )
 
+// GRPCAddr is the address the StatsCollector gRPC service listens on,
+// alongside the HTTP API.
+const GRPCAddr = ":9090"
+
+// JWTSecretEnv names the env var that enables JWT auth on the write/read
+// endpoints. Auth is disabled when it is unset or empty.
+const JWTSecretEnv = "JWT_SECRET"
+
+// MaxStatRange bounds how wide a /api/stat from/to window may be, so a
+// request can't force statRange to sum an unbounded number of hourly
+// buckets per URL.
+const MaxStatRange = 90 * 24 * time.Hour
+
 type Hit struct {
 	Referer string   `form:"referer" json:"referer" binding:"exists,alphanum,min=1,max=250"`
 	Urls    []string `form:"urls" json:"urls" binding:"exists"`
@@ -29,12 +51,50 @@ type StatResp struct {
 	CTR   float64
 }
 
+// TimeBucket is one point of the time-series returned by /api/stat when
+// a range is requested: the total views/clicks across every URL of the
+// referer during that bucket.
+type TimeBucket struct {
+	Time  string `json:"time"`
+	View  uint64 `json:"view"`
+	Click uint64 `json:"click"`
+}
+
+// StatRangeResp is returned by /api/stat/:referer instead of a bare
+// array when ?from=/&to= are given, so the per-URL stats can be
+// accompanied by an aggregate time-series.
+type StatRangeResp struct {
+	Stats      []StatResp   `json:"stats"`
+	TimeSeries []TimeBucket `json:"timeseries"`
+}
+
+// BatchHit is a single ingestion tuple accepted by /api/batch, mirroring
+// proto.Hit for clients that would rather speak JSON than protobuf.
+type BatchHit struct {
+	Referer   string `json:"referer" binding:"exists,alphanum,min=1,max=250"`
+	Url       string `json:"url" binding:"exists"`
+	Type      string `json:"type" binding:"exists,oneof=view click"`
+	Count     uint64 `json:"count"`
+	Timestamp int64  `json:"timestamp"`
+}
+
 func main() {
+	st := store.New(store.DefaultFlushInterval)
+
+	corsCfg, err := cors.LoadConfig()
+	if err != nil {
+		log.Fatal("cors config:", err)
+	}
+	jwtSecret := os.Getenv(JWTSecretEnv)
+
 	srv := &http.Server{
 		Addr:    ":8088",
-		Handler: InitRouter(),
+		Handler: InitRouter(st, jwtSecret, corsCfg),
 	}
 
+	gsrv := grpc.NewServer()
+	proto.RegisterStatsCollectorServer(gsrv, grpcserver.New(st, jwtSecret))
+
 	go func() {
 		// service connections
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -42,6 +102,16 @@ func main() {
 		}
 	}()
 
+	go func() {
+		lis, err := net.Listen("tcp", GRPCAddr)
+		if err != nil {
+			log.Fatalf("grpc listen: %s\n", err)
+		}
+		if err := gsrv.Serve(lis); err != nil {
+			log.Fatalf("grpc serve: %s\n", err)
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
 	quit := make(chan os.Signal)
@@ -54,21 +124,35 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("Server Shutdown:", err)
 	}
+	gsrv.GracefulStop()
 	// Close db
-	if err := sp.CloseAll(); err != nil {
+	if err := st.Close(); err != nil {
 		log.Fatal("Database Shutdown:", err)
 	}
 	log.Println("Server exiting")
 
 }
 
-func InitRouter() *gin.Engine {
+func InitRouter(st *store.Store, jwtSecret string, corsCfg cors.Config) *gin.Engine {
 	r := gin.Default()
 
-	r.Use(CORSMiddleware())
-	r.POST("/api/view", View)
-	r.POST("/api/click", Click)
-	r.GET("/api/stat/:referer", Stat)
+	if err := metrics.Register(st, prometheus.DefaultRegisterer); err != nil {
+		log.Fatal("metrics registration:", err)
+	}
+
+	r.Use(cors.Middleware(corsCfg))
+	r.Use(metrics.Middleware())
+
+	scoped := r.Group("/api")
+	if jwtSecret != "" {
+		scoped.Use(auth.Middleware([]byte(jwtSecret)), auth.RequireRefererScope())
+	}
+	scoped.POST("/view", View(st))
+	scoped.POST("/click", Click(st))
+	scoped.POST("/batch", Batch(st))
+	scoped.GET("/stat/:referer", Stat(st))
+
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	return r
 }
@@ -76,111 +160,197 @@ func InitRouter() *gin.Engine {
 // View register urls view
 // Example:
 // curl -d '{"referer":"hotpop","urls":["url1","url2"]}' -H "Content-Type: application/json" -X POST http://localhost:8088/api/view
-func View(c *gin.Context) {
-	var err error
-	switch c.Request.Method {
-	case "POST":
+func View(st *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		var h Hit
-		err = c.ShouldBind(&h)
-		if err != nil {
+		if err := c.ShouldBind(&h); err != nil {
 			c.JSON(http.StatusUnprocessableEntity, err)
 			return
 		}
-		if h.Urls != nil {
-			for _, u := range h.Urls {
-				sp.Counter("counters/view"+h.Referer, []byte(u))
-				sp.CloseAll()
-			}
-			c.JSON(http.StatusOK, h)
+		if h.Urls == nil {
+			c.JSON(http.StatusUnprocessableEntity, errors.New("empty urls"))
 			return
 		}
-		c.JSON(http.StatusUnprocessableEntity, errors.New("empty urls"))
+		for _, u := range h.Urls {
+			st.AddBucket(store.ViewPath(h.Referer), []byte(u), 1, time.Now())
+		}
+		c.JSON(http.StatusOK, h)
 	}
 }
 
 // Click register urls clicks
 // Example:
 // curl -d '{"referer":"hotpop","urls":["url1","url2"]}' -H "Content-Type: application/json" -X POST http://localhost:8088/api/click
-func Click(c *gin.Context) {
-	var err error
-	switch c.Request.Method {
-	case "POST":
+func Click(st *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		var h Hit
-		err = c.ShouldBind(&h)
-		if err != nil {
+		if err := c.ShouldBind(&h); err != nil {
 			c.JSON(http.StatusUnprocessableEntity, err)
 			return
 		}
-		if h.Urls != nil {
-			for _, u := range h.Urls {
-				sp.Counter("counters/click"+h.Referer, []byte(u))
-				sp.CloseAll()
-			}
-			c.JSON(http.StatusOK, h)
+		if h.Urls == nil {
+			c.JSON(http.StatusUnprocessableEntity, errors.New("empty urls"))
 			return
 		}
-		c.JSON(http.StatusUnprocessableEntity, errors.New("empty urls"))
+		for _, u := range h.Urls {
+			st.AddBucket(store.ClickPath(h.Referer), []byte(u), 1, time.Now())
+		}
+		c.JSON(http.StatusOK, h)
 	}
 }
 
-// Stat show stat
+// Batch registers many view/click hits in one call, for high-throughput
+// clients that would otherwise pay one HTTP round trip per URL.
 // Example:
+// curl -d '[{"referer":"hotpop","url":"url1","type":"view","count":5}]' -H "Content-Type: application/json" -X POST http://localhost:8088/api/batch
+func Batch(st *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hits []BatchHit
+		if err := c.ShouldBindJSON(&hits); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, err)
+			return
+		}
+		if len(hits) == 0 {
+			c.JSON(http.StatusUnprocessableEntity, errors.New("empty batch"))
+			return
+		}
+		for _, h := range hits {
+			path := store.ViewPath(h.Referer)
+			if h.Type == "click" {
+				path = store.ClickPath(h.Referer)
+			}
+			count := h.Count
+			if count == 0 {
+				count = 1
+			}
+			ts := time.Now()
+			if h.Timestamp != 0 {
+				ts = time.Unix(h.Timestamp, 0)
+			}
+			st.AddBucket(path, []byte(h.Url), count, ts)
+		}
+		c.JSON(http.StatusOK, gin.H{"accepted": len(hits)})
+	}
+}
+
+// Stat show stat
+// Example (all-time totals):
 // curl  -H "Content-Type: application/json" -X GET http://localhost:8088/api/stat/hotpop
-// [{"Url":"url1","View":3,"Click":2,"CTR":1.5},{"Url":"url2","View":3,"Click":2,"CTR":1.5}]
-func Stat(c *gin.Context) {
-	//var err error
-	referer := c.Param("referer")
-	//fmt.Println("referer", referer)
-	var resp []StatResp
-	resp = make([]StatResp, 0, 0)
-	switch c.Request.Method {
-	case "GET":
-		keys, err := sp.Keys("counters/view"+referer, nil, uint32(0), uint32(0), true)
-		fmt.Println(keys)
+// [{"Url":"url1","View":3,"Click":2,"CTR":0.6666666666666666},{"Url":"url2","View":3,"Click":2,"CTR":0.6666666666666666}]
+//
+// Example (time range, returns a StatRangeResp instead of a bare array):
+// curl -X GET "http://localhost:8088/api/stat/hotpop?from=1721000000&to=1721086400&granularity=hour"
+func Stat(st *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		referer := c.Param("referer")
+
+		keys, err := st.Keys(store.ViewPath(referer))
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, err)
 			return
 		}
-		for _, key := range keys {
-			var v, c uint64
-			vbin, err := sp.Get("counters/view"+referer, key)
-			if err == nil {
-				v = binary.BigEndian.Uint64(vbin)
-				cbin, err := sp.Get("counters/click"+referer, key)
-				if err == nil {
-					c = binary.BigEndian.Uint64(cbin)
-				}
-				var s StatResp
-				s.Url = string(key)
-				s.View = v
-				s.Click = c
-				if c > 0 {
-					s.CTR = float64(v) / float64(c)
-				} else {
-					s.CTR = 0
-				}
-				resp = append(resp, s)
-			}
 
+		from := c.DefaultQuery("from", "")
+		to := c.DefaultQuery("to", "")
+		if from == "" && to == "" {
+			c.JSON(http.StatusOK, statTotals(st, referer, keys))
+			return
+		}
+
+		fromTime, toTime, err := parseRange(from, to)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, err)
+			return
+		}
+		if toTime.Sub(fromTime) > MaxStatRange {
+			c.JSON(http.StatusUnprocessableEntity, fmt.Errorf("range too large: max %s", MaxStatRange))
+			return
+		}
+		granularity := c.DefaultQuery("granularity", "hour")
+		if granularity != "hour" && granularity != "day" {
+			c.JSON(http.StatusUnprocessableEntity, errors.New("granularity must be hour or day"))
+			return
+		}
+
+		stats, series := statRange(st, referer, keys, fromTime, toTime, granularity)
+		c.JSON(http.StatusOK, StatRangeResp{Stats: stats, TimeSeries: series})
+	}
+}
+
+// statTotals computes the all-time view/click totals for each url, the
+// legacy /api/stat response shape.
+func statTotals(st *store.Store, referer string, keys [][]byte) []StatResp {
+	resp := make([]StatResp, 0, len(keys))
+	for _, key := range keys {
+		v, err := st.Get(store.ViewPath(referer), key)
+		if err != nil {
+			continue
 		}
-		c.JSON(http.StatusOK, resp)
+		click, _ := st.Get(store.ClickPath(referer), key)
+		resp = append(resp, StatResp{Url: string(key), View: v, Click: click, CTR: ctr(v, click)})
 	}
+	return resp
 }
 
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+// statRange sums the hourly buckets between from and to for each url,
+// and builds an aggregate time-series at the requested granularity.
+func statRange(st *store.Store, referer string, keys [][]byte, from, to time.Time, granularity string) ([]StatResp, []TimeBucket) {
+	hours := store.HourlyBuckets(from, to)
+	stats := make([]StatResp, 0, len(keys))
+	buckets := make(map[string]*TimeBucket)
+	var order []string
+
+	for _, key := range keys {
+		var views, clicks uint64
+		for _, hour := range hours {
+			v, _ := st.Get(store.BucketPath(store.ViewPath(referer), hour), key)
+			c, _ := st.Get(store.BucketPath(store.ClickPath(referer), hour), key)
+			views += v
+			clicks += c
 
-		if c.Request.Method == "OPTIONS" {
-			//fmt.Println("OPTIONS")
-			c.AbortWithStatus(200)
-		} else {
-			c.Next()
+			bucketKey := hour.Format("2006010215")
+			if granularity == "day" {
+				bucketKey = hour.Format("20060102")
+			}
+			b, ok := buckets[bucketKey]
+			if !ok {
+				b = &TimeBucket{Time: bucketKey}
+				buckets[bucketKey] = b
+				order = append(order, bucketKey)
+			}
+			b.View += v
+			b.Click += c
 		}
+		stats = append(stats, StatResp{Url: string(key), View: views, Click: clicks, CTR: ctr(views, clicks)})
+	}
+
+	series := make([]TimeBucket, 0, len(order))
+	for _, k := range order {
+		series = append(series, *buckets[k])
+	}
+	return stats, series
+}
+
+func parseRange(from, to string) (time.Time, time.Time, error) {
+	fromUnix, err := strconv.ParseInt(from, 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("from must be a unix timestamp")
+	}
+	toUnix, err := strconv.ParseInt(to, 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("to must be a unix timestamp")
+	}
+	fromTime, toTime := time.Unix(fromUnix, 0), time.Unix(toUnix, 0)
+	if toTime.Before(fromTime) {
+		return time.Time{}, time.Time{}, errors.New("to must not be before from")
+	}
+	return fromTime, toTime, nil
+}
+
+// ctr is the click-through rate: clicks divided by views.
+func ctr(views, clicks uint64) float64 {
+	if views == 0 {
+		return 0
 	}
+	return float64(clicks) / float64(views)
 }
@@ -0,0 +1,122 @@
+// Package metrics exposes statscollector's internal state and request
+// handling as Prometheus metrics.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/recoilme/statscollector/store"
+)
+
+var (
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "statscollector_request_duration_seconds",
+		Help: "Latency of HTTP requests handled by statscollector.",
+	}, []string{"handler", "method"})
+
+	handlerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "statscollector_handler_errors_total",
+		Help: "Number of HTTP responses with a 4xx/5xx status code, by handler.",
+	}, []string{"handler", "status"})
+)
+
+// Register registers the collectors that back /metrics, including a
+// collector that reads view/click counters out of st on every scrape. It
+// must be called once, before the server starts serving /metrics.
+func Register(st *store.Store, reg prometheus.Registerer) error {
+	if err := reg.Register(requestDuration); err != nil {
+		return err
+	}
+	if err := reg.Register(handlerErrors); err != nil {
+		return err
+	}
+	return reg.Register(newStoreCollector(st))
+}
+
+// Middleware records per-handler request latency and error counts. It
+// should be installed before the route handlers in InitRouter.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		handler := c.FullPath()
+		if handler == "" {
+			handler = "unknown"
+		}
+		requestDuration.WithLabelValues(handler, c.Request.Method).Observe(time.Since(start).Seconds())
+		if status := c.Writer.Status(); status >= 400 {
+			handlerErrors.WithLabelValues(handler, strconv.Itoa(status)).Inc()
+		}
+	}
+}
+
+// storeCollector is a prometheus.Collector that sources view/click
+// counters directly from the store on every scrape, so /metrics always
+// reflects the latest counters instead of replaying request events.
+type storeCollector struct {
+	st *store.Store
+
+	views  *prometheus.Desc
+	clicks *prometheus.Desc
+}
+
+func newStoreCollector(st *store.Store) *storeCollector {
+	return &storeCollector{
+		st: st,
+		views: prometheus.NewDesc(
+			"statscollector_views_total",
+			"Total recorded views per referer and url.",
+			[]string{"referer", "url"}, nil,
+		),
+		clicks: prometheus.NewDesc(
+			"statscollector_clicks_total",
+			"Total recorded clicks per referer and url.",
+			[]string{"referer", "url"}, nil,
+		),
+	}
+}
+
+func (c *storeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.views
+	ch <- c.clicks
+}
+
+func (c *storeCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, referer := range c.st.Referers() {
+		for _, key := range c.urlsFor(referer) {
+			url := string(key)
+			if v, err := c.st.Get(store.ViewPath(referer), key); err == nil {
+				ch <- prometheus.MustNewConstMetric(c.views, prometheus.CounterValue, float64(v), referer, url)
+			}
+			if v, err := c.st.Get(store.ClickPath(referer), key); err == nil {
+				ch <- prometheus.MustNewConstMetric(c.clicks, prometheus.CounterValue, float64(v), referer, url)
+			}
+		}
+	}
+}
+
+// urlsFor returns every url that has recorded a view or a click for
+// referer, so a url with only clicks (no matching view key) still gets
+// its click counter scraped.
+func (c *storeCollector) urlsFor(referer string) [][]byte {
+	viewKeys, _ := c.st.Keys(store.ViewPath(referer))
+	clickKeys, _ := c.st.Keys(store.ClickPath(referer))
+
+	seen := make(map[string]struct{}, len(viewKeys)+len(clickKeys))
+	urls := make([][]byte, 0, len(viewKeys)+len(clickKeys))
+	for _, keys := range [][][]byte{viewKeys, clickKeys} {
+		for _, key := range keys {
+			if _, ok := seen[string(key)]; ok {
+				continue
+			}
+			seen[string(key)] = struct{}{}
+			urls = append(urls, key)
+		}
+	}
+	return urls
+}
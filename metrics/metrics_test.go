@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/recoilme/statscollector/store"
+)
+
+func TestStoreCollectorUrlsForUnionsViewAndClickKeys(t *testing.T) {
+	st := store.New(time.Hour)
+	defer st.Close()
+
+	st.Counter(store.ViewPath("hotpop"), []byte("viewed-only"))
+	st.Counter(store.ClickPath("hotpop"), []byte("clicked-only"))
+	st.Counter(store.ViewPath("hotpop"), []byte("both"))
+	st.Counter(store.ClickPath("hotpop"), []byte("both"))
+
+	c := newStoreCollector(st)
+	urls := c.urlsFor("hotpop")
+
+	want := map[string]bool{"viewed-only": false, "clicked-only": false, "both": false}
+	if len(urls) != len(want) {
+		t.Fatalf("urlsFor() = %v, want %d distinct urls", urls, len(want))
+	}
+	for _, u := range urls {
+		if _, ok := want[string(u)]; !ok {
+			t.Fatalf("urlsFor() returned unexpected url %q", u)
+		}
+		want[string(u)] = true
+	}
+	for url, seen := range want {
+		if !seen {
+			t.Errorf("urlsFor() missing url %q", url)
+		}
+	}
+}
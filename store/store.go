@@ -0,0 +1,265 @@
+// Package store wraps the slowpoke key/value engine with a long-lived
+// handle, safe concurrent access and batched writes, so callers no longer
+// need to open/close the underlying database file on every hit.
+package store
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+
+	sp "github.com/recoilme/slowpoke"
+)
+
+// DefaultFlushInterval is how often pending counters are coalesced and
+// written to slowpoke when no interval is given to New.
+const DefaultFlushInterval = 250 * time.Millisecond
+
+const keySep = "\x00"
+
+const (
+	viewPrefix  = "counters/view"
+	clickPrefix = "counters/click"
+)
+
+// hourLayout formats a time.Time into the hourly bucket suffix used by
+// BucketPath, e.g. "counters/viewhotpop/2026072714".
+const hourLayout = "2006010215"
+
+// Store serializes access to slowpoke and batches counter increments in
+// memory so that bursts of concurrent requests result in a handful of
+// writes instead of one per request.
+type Store struct {
+	mu          sync.RWMutex
+	pending     map[string]uint64
+	pendingKeys map[string]map[string]struct{}
+	referers    map[string]struct{}
+
+	flushEvery time.Duration
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// New creates a Store that flushes pending counters every flushEvery. A
+// flushEvery of 0 uses DefaultFlushInterval. The returned Store owns the
+// slowpoke process-wide handles; callers must call Close exactly once,
+// typically from the graceful-shutdown path.
+func New(flushEvery time.Duration) *Store {
+	if flushEvery <= 0 {
+		flushEvery = DefaultFlushInterval
+	}
+	s := &Store{
+		pending:     make(map[string]uint64),
+		pendingKeys: make(map[string]map[string]struct{}),
+		referers:    make(map[string]struct{}),
+		flushEvery:  flushEvery,
+		stop:        make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.flushLoop()
+	return s
+}
+
+// Counter increments the counter for key under path by one. The
+// increment is buffered in memory and applied to slowpoke on the next
+// flush.
+func (s *Store) Counter(path string, key []byte) {
+	s.Add(path, key, 1)
+}
+
+// Add increments the counter for key under path by delta. It is the
+// building block batch ingestion uses to apply a client-reported count in
+// one go instead of replaying delta single increments.
+func (s *Store) Add(path string, key []byte, delta uint64) {
+	s.mu.Lock()
+	s.pending[path+keySep+string(key)] += delta
+	keys, ok := s.pendingKeys[path]
+	if !ok {
+		keys = make(map[string]struct{})
+		s.pendingKeys[path] = keys
+	}
+	keys[string(key)] = struct{}{}
+	if referer, ok := refererFromPath(path); ok {
+		s.referers[referer] = struct{}{}
+	}
+	s.mu.Unlock()
+}
+
+// AddBucket increments both the all-time counter at path and the hourly
+// bucket counter for ts, so /api/stat can later sum over a time range in
+// addition to reading the all-time total.
+func (s *Store) AddBucket(path string, key []byte, delta uint64, ts time.Time) {
+	s.Add(path, key, delta)
+	s.Add(BucketPath(path, ts), key, delta)
+}
+
+// Referers returns the referers that have recorded at least one view or
+// click so far.
+func (s *Store) Referers() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	referers := make([]string, 0, len(s.referers))
+	for referer := range s.referers {
+		referers = append(referers, referer)
+	}
+	return referers
+}
+
+// ViewPath and ClickPath build the slowpoke path for a referer's all-time
+// view and click counters, respectively.
+func ViewPath(referer string) string  { return viewPrefix + referer }
+func ClickPath(referer string) string { return clickPrefix + referer }
+
+// MaxRefererLen is the longest referer ValidReferer accepts, matching the
+// "max=250" binding tag HTTP handlers apply to the same field.
+const MaxRefererLen = 250
+
+// ValidReferer reports whether referer is safe to concatenate into a
+// slowpoke path (via ViewPath/ClickPath/BucketPath): non-empty, no longer
+// than MaxRefererLen and restricted to alphanumerics, matching the
+// "alphanum,min=1,max=250" binding tag HTTP handlers enforce on the same
+// field. Callers that build store paths from a referer not already
+// validated by gin binding (e.g. the gRPC server) must check this first,
+// or a value like "../../../etc/cron.d/x" could escape the counters/
+// directory.
+func ValidReferer(referer string) bool {
+	if len(referer) == 0 || len(referer) > MaxRefererLen {
+		return false
+	}
+	for _, r := range referer {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// BucketPath builds the slowpoke path for the hourly bucket that ts falls
+// into, for the all-time counter path (as built by ViewPath/ClickPath).
+func BucketPath(path string, ts time.Time) string {
+	return path + "/" + ts.UTC().Format(hourLayout)
+}
+
+// HourlyBuckets returns the UTC hour boundaries, inclusive, that cover
+// [from, to] at one-hour resolution, suitable for passing to BucketPath.
+func HourlyBuckets(from, to time.Time) []time.Time {
+	from, to = from.UTC().Truncate(time.Hour), to.UTC().Truncate(time.Hour)
+	if to.Before(from) {
+		return nil
+	}
+	hours := make([]time.Time, 0, int(to.Sub(from)/time.Hour)+1)
+	for t := from; !t.After(to); t = t.Add(time.Hour) {
+		hours = append(hours, t)
+	}
+	return hours
+}
+
+func refererFromPath(path string) (string, bool) {
+	switch {
+	case strings.HasPrefix(path, viewPrefix):
+		referer := strings.TrimPrefix(path, viewPrefix)
+		return referer, !strings.Contains(referer, "/")
+	case strings.HasPrefix(path, clickPrefix):
+		referer := strings.TrimPrefix(path, clickPrefix)
+		return referer, !strings.Contains(referer, "/")
+	default:
+		return "", false
+	}
+}
+
+// Get returns the current value for key under path, including any
+// increments that have not been flushed to slowpoke yet.
+func (s *Store) Get(path string, key []byte) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var v uint64
+	vbin, err := sp.Get(path, key)
+	if err == nil {
+		v = binary.BigEndian.Uint64(vbin)
+	}
+	v += s.pending[path+keySep+string(key)]
+	return v, nil
+}
+
+// Keys returns all keys stored under path, including keys that have only
+// been buffered in memory and not flushed to slowpoke yet.
+func (s *Store) Keys(path string) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys, err := sp.Keys(path, nil, uint32(0), uint32(0), true)
+	pending := s.pendingKeys[path]
+	if len(pending) == 0 {
+		return keys, err
+	}
+	if err != nil {
+		keys = nil
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		seen[string(k)] = struct{}{}
+	}
+	for k := range pending {
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		keys = append(keys, []byte(k))
+	}
+	return keys, nil
+}
+
+// Close stops the flush loop, flushes any remaining counters and closes
+// every slowpoke database opened during the Store's lifetime. It must be
+// called only once, from the graceful-shutdown block in main.
+func (s *Store) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return sp.CloseAll()
+}
+
+func (s *Store) flushLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.flushEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush applies all buffered increments to slowpoke in a single
+// read-modify-write pass per key, regardless of how large the buffered
+// delta is. It holds s.mu for the whole pass, including the sp.Get/sp.Set
+// calls, so Get and Keys never observe a key as neither pending nor yet
+// written to slowpoke.
+func (s *Store) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.pending
+	s.pending = make(map[string]uint64)
+	s.pendingKeys = make(map[string]map[string]struct{})
+
+	for pk, delta := range pending {
+		parts := strings.SplitN(pk, keySep, 2)
+		path, key := parts[0], []byte(parts[1])
+
+		var v uint64
+		if vbin, err := sp.Get(path, key); err == nil {
+			v = binary.BigEndian.Uint64(vbin)
+		}
+		v += delta
+
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, v)
+		sp.Set(path, key, buf)
+	}
+}
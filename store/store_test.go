@@ -0,0 +1,107 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidReferer(t *testing.T) {
+	cases := []struct {
+		referer string
+		want    bool
+	}{
+		{"hotpop", true},
+		{"HotPop123", true},
+		{"", false},
+		{"../../../etc/cron.d/x", false},
+		{"has/slash", false},
+		{"has space", false},
+		{strings.Repeat("a", MaxRefererLen), true},
+		{strings.Repeat("a", MaxRefererLen+1), false},
+	}
+	for _, tc := range cases {
+		if got := ValidReferer(tc.referer); got != tc.want {
+			t.Errorf("ValidReferer(%q) = %v, want %v", tc.referer, got, tc.want)
+		}
+	}
+}
+
+func TestKeysIncludesPendingKeys(t *testing.T) {
+	s := New(time.Hour)
+	defer s.Close()
+
+	path := ViewPath("pendingtest")
+	s.Counter(path, []byte("url1"))
+
+	keys, err := s.Keys(path)
+	if err != nil {
+		t.Fatalf("Keys() error = %v", err)
+	}
+	if len(keys) != 1 || string(keys[0]) != "url1" {
+		t.Fatalf("Keys() = %v, want [url1] (buffered but unflushed key must still be visible)", keys)
+	}
+}
+
+func TestHourlyBuckets(t *testing.T) {
+	from := time.Date(2026, 7, 27, 10, 30, 0, 0, time.UTC)
+	to := time.Date(2026, 7, 27, 12, 15, 0, 0, time.UTC)
+
+	hours := HourlyBuckets(from, to)
+
+	want := []time.Time{
+		time.Date(2026, 7, 27, 10, 0, 0, 0, time.UTC),
+		time.Date(2026, 7, 27, 11, 0, 0, 0, time.UTC),
+		time.Date(2026, 7, 27, 12, 0, 0, 0, time.UTC),
+	}
+	if len(hours) != len(want) {
+		t.Fatalf("got %d buckets, want %d: %v", len(hours), len(want), hours)
+	}
+	for i, h := range hours {
+		if !h.Equal(want[i]) {
+			t.Errorf("bucket %d = %v, want %v", i, h, want[i])
+		}
+	}
+}
+
+func TestHourlyBucketsToBeforeFrom(t *testing.T) {
+	from := time.Date(2026, 7, 27, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 7, 27, 10, 0, 0, 0, time.UTC)
+
+	if hours := HourlyBuckets(from, to); hours != nil {
+		t.Fatalf("expected nil buckets when to is before from, got %v", hours)
+	}
+}
+
+func TestBucketPath(t *testing.T) {
+	ts := time.Date(2026, 7, 27, 14, 45, 0, 0, time.UTC)
+
+	got := BucketPath("counters/viewhotpop", ts)
+	want := "counters/viewhotpop/2026072714"
+	if got != want {
+		t.Fatalf("BucketPath() = %q, want %q", got, want)
+	}
+}
+
+func TestRefererFromPathIgnoresBucketPaths(t *testing.T) {
+	cases := []struct {
+		path      string
+		wantOK    bool
+		wantValue string
+	}{
+		{"counters/viewhotpop", true, "hotpop"},
+		{"counters/clickhotpop", true, "hotpop"},
+		{"counters/viewhotpop/2026072714", false, ""},
+		{"something/else", false, ""},
+	}
+	for _, tc := range cases {
+		referer, ok := refererFromPath(tc.path)
+		if ok != tc.wantOK {
+			t.Errorf("refererFromPath(%q) ok = %v, want %v", tc.path, ok, tc.wantOK)
+			continue
+		}
+		if ok && referer != tc.wantValue {
+			t.Errorf("refererFromPath(%q) = %q, want %q", tc.path, referer, tc.wantValue)
+		}
+	}
+}